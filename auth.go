@@ -0,0 +1,252 @@
+package secretManager
+
+import (
+	"context"
+	"fmt"
+
+	vault "github.com/hashicorp/vault/api"
+	awsAuth "github.com/hashicorp/vault/api/auth/aws"
+	azureAuth "github.com/hashicorp/vault/api/auth/azure"
+	gcpAuth "github.com/hashicorp/vault/api/auth/gcp"
+	ldapAuth "github.com/hashicorp/vault/api/auth/ldap"
+	userpassAuth "github.com/hashicorp/vault/api/auth/userpass"
+)
+
+// AuthMethod is implemented by every Vault authentication backend this
+// package supports. It mirrors vault/api.AuthMethod so the stock AppRole and
+// Kubernetes implementations satisfy it unmodified.
+type AuthMethod interface {
+	Login(ctx context.Context, client *vault.Client) (*vault.Secret, error)
+}
+
+// AuthOption configures the mount path an auth backend logs in against.
+// Vault allows mounting the same auth engine at arbitrary paths (e.g.
+// auth/kubernetes-prod/). There is deliberately no per-method namespace
+// option: client.Auth().Login sets the resulting token on the manager's base
+// client, so a login scoped to a different namespace than that client would
+// produce a token that's unusable for every call made after it. Use
+// WithNamespace on NewSecretManager to scope the whole manager, including
+// logins, to a namespace instead.
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	mountPath string
+}
+
+// WithAuthMount overrides the default mount path of an auth backend.
+func WithAuthMount(mountPath string) AuthOption {
+	return func(o *authOptions) { o.mountPath = mountPath }
+}
+
+func buildAuthOptions(opts ...AuthOption) authOptions {
+	var o authOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UserpassAuth authenticates against the userpass auth engine.
+type UserpassAuth struct {
+	auth *userpassAuth.UserpassAuth
+}
+
+// NewUserpassAuth builds a UserpassAuth for username/password.
+func NewUserpassAuth(username, password string, opts ...AuthOption) (*UserpassAuth, error) {
+	o := buildAuthOptions(opts...)
+
+	auth, err := userpassAuth.NewUserpassAuth(username, &userpassAuth.Password{FromString: password}, userpassLoginOptions(o)...)
+	if err != nil {
+		return nil, fmt.Errorf("configuring userpass auth: %w", err)
+	}
+
+	return &UserpassAuth{auth: auth}, nil
+}
+
+func (a *UserpassAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	return a.auth.Login(ctx, client)
+}
+
+func userpassLoginOptions(o authOptions) []userpassAuth.LoginOption {
+	var opts []userpassAuth.LoginOption
+	if o.mountPath != "" {
+		opts = append(opts, userpassAuth.WithMountPath(o.mountPath))
+	}
+	return opts
+}
+
+// LDAPAuth authenticates against the LDAP auth engine.
+type LDAPAuth struct {
+	auth *ldapAuth.LDAPAuth
+}
+
+// NewLDAPAuth builds an LDAPAuth for username/password.
+func NewLDAPAuth(username, password string, opts ...AuthOption) (*LDAPAuth, error) {
+	o := buildAuthOptions(opts...)
+
+	var ldapOpts []ldapAuth.LoginOption
+	if o.mountPath != "" {
+		ldapOpts = append(ldapOpts, ldapAuth.WithMountPath(o.mountPath))
+	}
+
+	auth, err := ldapAuth.NewLDAPAuth(username, &ldapAuth.Password{FromString: password}, ldapOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("configuring LDAP auth: %w", err)
+	}
+
+	return &LDAPAuth{auth: auth}, nil
+}
+
+func (a *LDAPAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	return a.auth.Login(ctx, client)
+}
+
+// JWTAuth authenticates against the JWT/OIDC auth engine using a pre-issued
+// JWT (e.g. from an OIDC provider or a workload identity token).
+type JWTAuth struct {
+	mountPath string
+	role      string
+	jwt       string
+}
+
+// NewJWTAuth builds a JWTAuth. The mount path defaults to "jwt".
+func NewJWTAuth(role, jwt string, opts ...AuthOption) *JWTAuth {
+	o := buildAuthOptions(opts...)
+
+	mountPath := o.mountPath
+	if mountPath == "" {
+		mountPath = "jwt"
+	}
+
+	return &JWTAuth{mountPath: mountPath, role: role, jwt: jwt}
+}
+
+func (a *JWTAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", a.mountPath), map[string]interface{}{
+		"role": a.role,
+		"jwt":  a.jwt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jwt login: %w", err)
+	}
+	return secret, nil
+}
+
+// TLSCertAuth authenticates using the client TLS certificate already
+// configured on the secretManager's HTTP transport, against the cert auth
+// engine.
+type TLSCertAuth struct {
+	mountPath string
+	certName  string
+}
+
+// NewTLSCertAuth builds a TLSCertAuth. certName selects a specific
+// configured CA certificate role server-side; leave it empty to let Vault
+// match any configured certificate. The mount path defaults to "cert".
+func NewTLSCertAuth(certName string, opts ...AuthOption) *TLSCertAuth {
+	o := buildAuthOptions(opts...)
+
+	mountPath := o.mountPath
+	if mountPath == "" {
+		mountPath = "cert"
+	}
+
+	return &TLSCertAuth{mountPath: mountPath, certName: certName}
+}
+
+func (a *TLSCertAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	data := map[string]interface{}{}
+	if a.certName != "" {
+		data["name"] = a.certName
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", a.mountPath), data)
+	if err != nil {
+		return nil, fmt.Errorf("cert login: %w", err)
+	}
+	return secret, nil
+}
+
+// AWSAuth authenticates against the aws auth engine, using awsAuth's default
+// login type.
+type AWSAuth struct {
+	auth *awsAuth.AWSAuth
+}
+
+// NewAWSAuth builds an AWSAuth. Only the mount path is configurable; other
+// awsAuth login options cannot be passed through, so EC2 vs. IAM
+// authentication cannot be selected here.
+func NewAWSAuth(opts ...AuthOption) (*AWSAuth, error) {
+	o := buildAuthOptions(opts...)
+
+	var awsOpts []awsAuth.LoginOption
+	if o.mountPath != "" {
+		awsOpts = append(awsOpts, awsAuth.WithMountPath(o.mountPath))
+	}
+
+	auth, err := awsAuth.NewAWSAuth(awsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("configuring AWS auth: %w", err)
+	}
+
+	return &AWSAuth{auth: auth}, nil
+}
+
+func (a *AWSAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	return a.auth.Login(ctx, client)
+}
+
+// GCPAuth authenticates against the gcp auth engine, using gcpAuth's default
+// login type.
+type GCPAuth struct {
+	auth *gcpAuth.GCPAuth
+}
+
+// NewGCPAuth builds a GCPAuth for role. Only the mount path is configurable;
+// GCE vs. IAM authentication cannot be selected here.
+func NewGCPAuth(role string, opts ...AuthOption) (*GCPAuth, error) {
+	o := buildAuthOptions(opts...)
+
+	var gcpOpts []gcpAuth.LoginOption
+	if o.mountPath != "" {
+		gcpOpts = append(gcpOpts, gcpAuth.WithMountPath(o.mountPath))
+	}
+
+	auth, err := gcpAuth.NewGCPAuth(role, gcpOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("configuring GCP auth: %w", err)
+	}
+
+	return &GCPAuth{auth: auth}, nil
+}
+
+func (a *GCPAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	return a.auth.Login(ctx, client)
+}
+
+// AzureAuth authenticates against the azure auth engine using the instance's
+// managed identity.
+type AzureAuth struct {
+	auth *azureAuth.AzureAuth
+}
+
+// NewAzureAuth builds an AzureAuth for role.
+func NewAzureAuth(role string, opts ...AuthOption) (*AzureAuth, error) {
+	o := buildAuthOptions(opts...)
+
+	var azureOpts []azureAuth.LoginOption
+	if o.mountPath != "" {
+		azureOpts = append(azureOpts, azureAuth.WithMountPath(o.mountPath))
+	}
+
+	auth, err := azureAuth.NewAzureAuth(role, azureOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("configuring Azure auth: %w", err)
+	}
+
+	return &AzureAuth{auth: auth}, nil
+}
+
+func (a *AzureAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	return a.auth.Login(ctx, client)
+}
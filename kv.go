@@ -0,0 +1,278 @@
+package secretManager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// KVEngine identifies which version of the KV secrets engine a mount runs.
+type KVEngine int
+
+const (
+	// KVEngineUnknown is returned when the mount's version could not be determined.
+	KVEngineUnknown KVEngine = iota
+	KVEngineV1
+	KVEngineV2
+)
+
+// KVSecret is the decoded response from a KV read, normalised across v1 and v2
+// so callers don't need to branch on engine version.
+type KVSecret struct {
+	Data     map[string]interface{}
+	Metadata map[string]interface{}
+	Version  int
+}
+
+// KVOption configures a single KV operation.
+type KVOption func(*kvOptions)
+
+type kvOptions struct {
+	version int
+}
+
+// KVVersion pins a read or delete to a specific KV v2 version. Ignored
+// against a v1 mount. UndeleteKVVersions and DestroyKVVersions take their
+// versions as arguments instead.
+func KVVersion(version int) KVOption {
+	return func(o *kvOptions) { o.version = version }
+}
+
+func buildKVOptions(opts ...KVOption) kvOptions {
+	var o kvOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UsernamePassword is a typed convenience target for KVCredential.
+type UsernamePassword struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// APIKey is a typed convenience target for KVAPIKey.
+type APIKey struct {
+	Key string `mapstructure:"api_key"`
+}
+
+func (s *secretManager) engineForMount(ctx context.Context, mount string) (KVEngine, error) {
+	mount = strings.Trim(mount, "/")
+
+	s.kvEnginesMu.RLock()
+	engine, ok := s.kvEngines[mount]
+	s.kvEnginesMu.RUnlock()
+	if ok {
+		return engine, nil
+	}
+
+	mounts, err := s.client.Sys().ListMountsWithContext(ctx)
+	if err != nil {
+		return KVEngineUnknown, fmt.Errorf("listing mounts: %w", err)
+	}
+
+	out, ok := mounts[mount+"/"]
+	if !ok {
+		return KVEngineUnknown, fmt.Errorf("no mount found at %q", mount)
+	}
+
+	engine = KVEngineV1
+	if out.Options["version"] == "2" {
+		engine = KVEngineV2
+	}
+
+	s.kvEnginesMu.Lock()
+	s.kvEngines[mount] = engine
+	s.kvEnginesMu.Unlock()
+
+	return engine, nil
+}
+
+func kvDataPath(engine KVEngine, mount, segment, path string) string {
+	mount = strings.Trim(mount, "/")
+	path = strings.Trim(path, "/")
+	if engine != KVEngineV2 {
+		return mount + "/" + path
+	}
+	return mount + "/" + segment + "/" + path
+}
+
+// ReadKV reads a secret from mount at path, transparently handling KV v1 and
+// v2 addressing. Pass KVVersion to read a specific v2 version.
+func (s *secretManager) ReadKV(ctx context.Context, mount, path string, opts ...KVOption) (*KVSecret, error) {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return nil, err
+	}
+	o := buildKVOptions(opts...)
+
+	readPath := kvDataPath(engine, mount, "data", path)
+	var params map[string][]string
+	if engine == KVEngineV2 && o.version > 0 {
+		params = map[string][]string{"version": {fmt.Sprintf("%d", o.version)}}
+	}
+
+	secret, err := s.client.Logical().ReadWithDataWithContext(ctx, readPath, params)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", readPath, err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no secret found at %s", readPath)
+	}
+
+	if engine != KVEngineV2 {
+		return &KVSecret{Data: secret.Data}, nil
+	}
+
+	data, _ := secret.Data["data"].(map[string]interface{})
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	version := 0
+	if v, ok := metadata["version"].(float64); ok {
+		version = int(v)
+	}
+
+	return &KVSecret{Data: data, Metadata: metadata, Version: version}, nil
+}
+
+// WriteKV writes data to mount at path, transparently handling KV v1 and v2
+// addressing.
+func (s *secretManager) WriteKV(ctx context.Context, mount, path string, data map[string]interface{}) error {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return err
+	}
+
+	writePath := kvDataPath(engine, mount, "data", path)
+	payload := data
+	if engine == KVEngineV2 {
+		payload = map[string]interface{}{"data": data}
+	}
+
+	if _, err := s.client.Logical().WriteWithContext(ctx, writePath, payload); err != nil {
+		return fmt.Errorf("writing %s: %w", writePath, err)
+	}
+	return nil
+}
+
+// DeleteKV deletes the latest version of a secret. Against KV v2, pass
+// KVVersion to delete a specific version instead of the current one.
+func (s *secretManager) DeleteKV(ctx context.Context, mount, path string, opts ...KVOption) error {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return err
+	}
+	o := buildKVOptions(opts...)
+
+	if engine != KVEngineV2 || o.version == 0 {
+		deletePath := kvDataPath(engine, mount, "data", path)
+		if _, err := s.client.Logical().DeleteWithContext(ctx, deletePath); err != nil {
+			return fmt.Errorf("deleting %s: %w", deletePath, err)
+		}
+		return nil
+	}
+
+	deletePath := kvDataPath(engine, mount, "delete", path)
+	if _, err := s.client.Logical().WriteWithContext(ctx, deletePath, map[string]interface{}{
+		"versions": []int{o.version},
+	}); err != nil {
+		return fmt.Errorf("deleting %s: %w", deletePath, err)
+	}
+	return nil
+}
+
+// UndeleteKVVersions restores soft-deleted versions of a KV v2 secret.
+func (s *secretManager) UndeleteKVVersions(ctx context.Context, mount, path string, versions ...int) error {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return err
+	}
+	if engine != KVEngineV2 {
+		return fmt.Errorf("%s is not a KV v2 mount", mount)
+	}
+
+	undeletePath := kvDataPath(engine, mount, "undelete", path)
+	if _, err := s.client.Logical().WriteWithContext(ctx, undeletePath, map[string]interface{}{
+		"versions": versions,
+	}); err != nil {
+		return fmt.Errorf("undeleting %s: %w", undeletePath, err)
+	}
+	return nil
+}
+
+// DestroyKVVersions permanently removes the underlying data for the given
+// versions of a KV v2 secret.
+func (s *secretManager) DestroyKVVersions(ctx context.Context, mount, path string, versions ...int) error {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return err
+	}
+	if engine != KVEngineV2 {
+		return fmt.Errorf("%s is not a KV v2 mount", mount)
+	}
+
+	destroyPath := kvDataPath(engine, mount, "destroy", path)
+	if _, err := s.client.Logical().WriteWithContext(ctx, destroyPath, map[string]interface{}{
+		"versions": versions,
+	}); err != nil {
+		return fmt.Errorf("destroying %s: %w", destroyPath, err)
+	}
+	return nil
+}
+
+// ListKV lists the secret keys beneath path.
+func (s *secretManager) ListKV(ctx context.Context, mount, path string) ([]string, error) {
+	engine, err := s.engineForMount(ctx, mount)
+	if err != nil {
+		return nil, err
+	}
+
+	listPath := kvDataPath(engine, mount, "metadata", path)
+	secret, err := s.client.Logical().ListWithContext(ctx, listPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", listPath, err)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	raw, _ := secret.Data["keys"].([]interface{})
+	keys := make([]string, 0, len(raw))
+	for _, k := range raw {
+		if s, ok := k.(string); ok {
+			keys = append(keys, s)
+		}
+	}
+	return keys, nil
+}
+
+// KVCredential reads path and decodes it into a UsernamePassword.
+func (s *secretManager) KVCredential(ctx context.Context, mount, path string) (*UsernamePassword, error) {
+	var cred UsernamePassword
+	if err := s.decodeKV(ctx, mount, path, &cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// KVAPIKey reads path and decodes it into an APIKey.
+func (s *secretManager) KVAPIKey(ctx context.Context, mount, path string) (*APIKey, error) {
+	var key APIKey
+	if err := s.decodeKV(ctx, mount, path, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *secretManager) decodeKV(ctx context.Context, mount, path string, out interface{}) error {
+	secret, err := s.ReadKV(ctx, mount, path)
+	if err != nil {
+		return err
+	}
+	if err := mapstructure.Decode(secret.Data, out); err != nil {
+		return fmt.Errorf("decoding secret at %s: %w", path, err)
+	}
+	return nil
+}
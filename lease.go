@@ -0,0 +1,149 @@
+package secretManager
+
+import (
+	"context"
+	"fmt"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+// AWSCreds is a typed convenience target for AWSSTSCredential.
+type AWSCreds struct {
+	AccessKey     string `mapstructure:"access_key"`
+	SecretKey     string `mapstructure:"secret_key"`
+	SecurityToken string `mapstructure:"security_token"`
+}
+
+// LeaseHandle represents a checked-out dynamic secret. Callers should Close
+// it once the credential is no longer needed so Vault revokes it promptly
+// instead of waiting out its TTL.
+type LeaseHandle struct {
+	manager *secretManager
+	leaseID string
+	renewal *LifetimeManager
+}
+
+// LeaseID is the Vault lease identifier backing this handle.
+func (h *LeaseHandle) LeaseID() string {
+	return h.leaseID
+}
+
+// Close stops the handle's background renewer, if any, and revokes the
+// lease.
+func (h *LeaseHandle) Close() error {
+	if h.renewal != nil {
+		h.renewal.Stop()
+	}
+	return h.manager.revokeLease(h.leaseID)
+}
+
+// checkoutLease registers secret's lease so Shutdown can revoke it, and, if
+// the lease is renewable, starts a lightweight renewer for it. Dynamic
+// secrets use secret.Renewable rather than secret.Auth.Renewable, which only
+// applies to auth tokens.
+func (s *secretManager) checkoutLease(secret *vault.Secret) *LeaseHandle {
+	handle := &LeaseHandle{manager: s, leaseID: secret.LeaseID}
+
+	if secret.Renewable {
+		handle.renewal = newLifetimeManager(s.client, secret, nil)
+
+		go func() {
+			for err := range handle.renewal.Errors() {
+				logger.Error("lease renewal error", "leaseId", secret.LeaseID, "error", err.Error())
+			}
+		}()
+		go func() {
+			if err := handle.renewal.Start(s.ctx); err != nil {
+				logger.Error("lease renewer stopped", "leaseId", secret.LeaseID, "error", err.Error())
+			}
+		}()
+	}
+
+	s.leasesMu.Lock()
+	s.leases[secret.LeaseID] = handle
+	s.leasesMu.Unlock()
+
+	return handle
+}
+
+func (s *secretManager) revokeLease(leaseID string) error {
+	s.leasesMu.Lock()
+	delete(s.leases, leaseID)
+	s.leasesMu.Unlock()
+
+	if leaseID == "" {
+		return nil
+	}
+	if err := s.client.Sys().Revoke(leaseID); err != nil {
+		return fmt.Errorf("revoking lease %s: %w", leaseID, err)
+	}
+	return nil
+}
+
+// Shutdown revokes every outstanding lease checked out through DBCredential,
+// AWSSTSCredential and friends, and stops their renewers.
+func (s *secretManager) Shutdown() error {
+	s.leasesMu.Lock()
+	leases := make([]*LeaseHandle, 0, len(s.leases))
+	for _, handle := range s.leases {
+		leases = append(leases, handle)
+	}
+	s.leasesMu.Unlock()
+
+	var errs []error
+	for _, handle := range leases {
+		if err := handle.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if mgr := s.currentLifetimeManager(); mgr != nil {
+		mgr.Stop()
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("revoking %d lease(s): %w", len(errs), errs[0])
+	}
+	return nil
+}
+
+// DBCredential checks out a dynamic database credential for role from the
+// database secrets engine. Close the returned LeaseHandle once the
+// credential is no longer needed.
+func (s *secretManager) DBCredential(ctx context.Context, role string) (*UsernamePassword, *LeaseHandle, error) {
+	secret, err := s.client.Logical().ReadWithContext(ctx, fmt.Sprintf("database/creds/%s", role))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading database credential for role %s: %w", role, err)
+	}
+	if secret == nil {
+		return nil, nil, fmt.Errorf("no database credential returned for role %s", role)
+	}
+
+	var cred UsernamePassword
+	if err := mapstructure.Decode(secret.Data, &cred); err != nil {
+		return nil, nil, fmt.Errorf("decoding database credential for role %s: %w", role, err)
+	}
+
+	return &cred, s.checkoutLease(secret), nil
+}
+
+// AWSSTSCredential checks out dynamic, STS-issued AWS credentials for role
+// from the aws secrets engine. Close the returned LeaseHandle once the
+// credential is no longer needed.
+func (s *secretManager) AWSSTSCredential(ctx context.Context, role string) (*AWSCreds, *LeaseHandle, error) {
+	secret, err := s.client.Logical().ReadWithContext(ctx, fmt.Sprintf("aws/sts/%s", role))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading AWS STS credential for role %s: %w", role, err)
+	}
+	if secret == nil {
+		return nil, nil, fmt.Errorf("no AWS STS credential returned for role %s", role)
+	}
+
+	var creds AWSCreds
+	if err := mapstructure.Decode(secret.Data, &creds); err != nil {
+		return nil, nil, fmt.Errorf("decoding AWS STS credential for role %s: %w", role, err)
+	}
+
+	return &creds, s.checkoutLease(secret), nil
+}
@@ -0,0 +1,199 @@
+package secretManager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// renewalFraction is how far into a lease's TTL the manager schedules the
+// next renewal, so tokens are refreshed well before they expire rather than
+// racing the deadline. It only governs scheduling; renewals still request
+// the lease's full original duration.
+const renewalFraction = 0.8
+
+// reauthBackoff is how long the manager waits before logging in again after
+// hitting a non-renewable secret. If the fresh secret is not renewable
+// either, the manager gives up, so a permanently non-renewable auth method
+// (e.g. a role issuing batch tokens) can't spin the loop.
+const reauthBackoff = 30 * time.Second
+
+// minRenewalWait floors the computed time to the next renewal, so a secret
+// with a zero or tiny lease duration can't spin the loop hammering Vault.
+const minRenewalWait = 5 * time.Second
+
+// loginFunc re-authenticates and returns a fresh auth secret. It is supplied
+// by whichever Login* method started the manager, so the manager can recover
+// once a lease can no longer be renewed.
+type loginFunc func(ctx context.Context) (*vault.Secret, error)
+
+// LifetimeManager proactively renews a Vault lease and falls back to
+// re-authenticating via loginFunc when the lease can no longer be renewed,
+// instead of letting the token expire.
+type LifetimeManager struct {
+	client *vault.Client
+	login  loginFunc
+	errCh  chan error
+
+	secret chan *vault.Secret
+	stop   chan struct{}
+}
+
+func newLifetimeManager(client *vault.Client, secret *vault.Secret, login loginFunc) *LifetimeManager {
+	m := &LifetimeManager{
+		client: client,
+		login:  login,
+		errCh:  make(chan error, 1),
+		secret: make(chan *vault.Secret, 1),
+		stop:   make(chan struct{}),
+	}
+	m.secret <- secret
+	return m
+}
+
+// Errors surfaces every renewal failure, including ones then recovered from
+// by re-authenticating, and every re-authentication failure. Errors sent
+// while the buffer is full are dropped. The caller should drain it; it is
+// closed when Start returns.
+func (m *LifetimeManager) Errors() <-chan error {
+	return m.errCh
+}
+
+// Stop ends the renewal loop. It is safe to call more than once.
+func (m *LifetimeManager) Stop() {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+}
+
+// Start runs the renewal loop until ctx is cancelled, Stop is called, or
+// re-authentication fails (in which case it returns the error). It blocks,
+// so callers typically invoke it in its own goroutine.
+func (m *LifetimeManager) Start(ctx context.Context) error {
+	defer close(m.errCh)
+
+	secret := <-m.secret
+	for {
+		wait, renewable := nextRenewal(secret)
+		if !renewable {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-m.stop:
+				return nil
+			case <-time.After(reauthBackoff):
+			}
+
+			renewed, err := m.reauthenticate(ctx)
+			if err != nil {
+				return err
+			}
+			if _, stillRenewable := nextRenewal(renewed); !stillRenewable {
+				err := fmt.Errorf("re-authenticated secret is still not renewable, giving up")
+				m.emit(err)
+				return err
+			}
+
+			secret = renewed
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.stop:
+			return nil
+		case <-time.After(wait):
+		}
+
+		renewed, err := m.renew(ctx, secret)
+		if err != nil {
+			logger.Warn("lease renewal failed, re-authenticating", "error", err.Error())
+			m.emit(err)
+
+			renewed, err = m.reauthenticate(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			logger.Info("lease was renewed", "leaseDuration", leaseDuration(renewed))
+		}
+
+		secret = renewed
+	}
+}
+
+func (m *LifetimeManager) renew(ctx context.Context, secret *vault.Secret) (*vault.Secret, error) {
+	// Request the lease's original duration, not the 80% fraction used for
+	// scheduling: that fraction only decides when to renew, not how much TTL
+	// to ask for, or the granted TTL would shrink a little further each
+	// cycle.
+	increment := leaseDuration(secret)
+
+	if secret.Auth != nil {
+		renewed, err := m.client.Auth().Token().RenewTokenAsSelfWithContext(ctx, secret.Auth.ClientToken, increment)
+		if err != nil {
+			return nil, fmt.Errorf("renewing token: %w", err)
+		}
+		return renewed, nil
+	}
+
+	renewed, err := m.client.Sys().RenewWithContext(ctx, secret.LeaseID, increment)
+	if err != nil {
+		return nil, fmt.Errorf("renewing lease %s: %w", secret.LeaseID, err)
+	}
+	return renewed, nil
+}
+
+func (m *LifetimeManager) reauthenticate(ctx context.Context) (*vault.Secret, error) {
+	if m.login == nil {
+		err := fmt.Errorf("secret can no longer be renewed and no login callback is available")
+		m.emit(err)
+		return nil, err
+	}
+
+	secret, err := m.login(ctx)
+	if err != nil {
+		err = fmt.Errorf("re-authenticating after renewal failure: %w", err)
+		m.emit(err)
+		return nil, err
+	}
+
+	logger.Info("re-authenticated after renewal failure")
+	return secret, nil
+}
+
+func (m *LifetimeManager) emit(err error) {
+	select {
+	case m.errCh <- err:
+	default:
+	}
+}
+
+func nextRenewal(secret *vault.Secret) (wait time.Duration, renewable bool) {
+	if secret.Auth != nil {
+		if !secret.Auth.Renewable {
+			return 0, false
+		}
+	} else if !secret.Renewable {
+		return 0, false
+	}
+
+	ttl := time.Duration(leaseDuration(secret)) * time.Second
+	wait = time.Duration(float64(ttl) * renewalFraction)
+	if wait < minRenewalWait {
+		wait = minRenewalWait
+	}
+	return wait, true
+}
+
+func leaseDuration(secret *vault.Secret) int {
+	if secret.Auth != nil {
+		return secret.Auth.LeaseDuration
+	}
+	return secret.LeaseDuration
+}
@@ -0,0 +1,75 @@
+package secretManager
+
+import (
+	"context"
+	"net/http"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// SecretManagerOption configures NewSecretManager.
+type SecretManagerOption func(*secretManagerOptions) error
+
+type secretManagerOptions struct {
+	namespace  string
+	tlsConfig  *vault.TLSConfig
+	httpClient *http.Client
+	maxRetries int
+	ctx        context.Context
+}
+
+// WithNamespace scopes the client to a Vault Enterprise namespace.
+func WithNamespace(namespace string) SecretManagerOption {
+	return func(o *secretManagerOptions) error {
+		o.namespace = namespace
+		return nil
+	}
+}
+
+// WithTLSConfig configures the client's TLS transport, e.g. custom CA
+// certificates or client certificates.
+func WithTLSConfig(config *vault.TLSConfig) SecretManagerOption {
+	return func(o *secretManagerOptions) error {
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithHTTPClient overrides the client's underlying *http.Client.
+func WithHTTPClient(httpClient *http.Client) SecretManagerOption {
+	return func(o *secretManagerOptions) error {
+		o.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithMaxRetries sets how many times the client retries a request against a
+// sealed or unreachable Vault before giving up.
+func WithMaxRetries(maxRetries int) SecretManagerOption {
+	return func(o *secretManagerOptions) error {
+		o.maxRetries = maxRetries
+		return nil
+	}
+}
+
+// WithContext sets the context used for logins and lifetime management when
+// the caller doesn't supply one directly, e.g. LoginWithAppRole and the
+// background renewal loop started after a successful login.
+func WithContext(ctx context.Context) SecretManagerOption {
+	return func(o *secretManagerOptions) error {
+		o.ctx = ctx
+		return nil
+	}
+}
+
+func buildSecretManagerOptions(opts ...SecretManagerOption) (secretManagerOptions, error) {
+	// maxRetries of -1 means WithMaxRetries was not given, so the client's
+	// default is kept; WithMaxRetries(0) disables retries.
+	o := secretManagerOptions{ctx: context.Background(), maxRetries: -1}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return secretManagerOptions{}, err
+		}
+	}
+	return o, nil
+}
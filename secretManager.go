@@ -2,13 +2,13 @@ package secretManager
 
 import (
 	"context"
-	"errors"
 	vault "github.com/hashicorp/vault/api"
 	approleAuth "github.com/hashicorp/vault/api/auth/approle"
 	kubernetesAuth "github.com/hashicorp/vault/api/auth/kubernetes"
-	"log"
+	"io"
 	"log/slog"
 	"os"
+	"sync"
 )
 
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{}))
@@ -18,49 +18,143 @@ type SecretManager interface {
 	LoginWithAppRole(approleId string, approleSecret string) error
 	LoginWithToken(token string)
 	LoginWithKubernetes(role string, opts ...kubernetesAuth.LoginOption) error
+	// Login authenticates using any AuthMethod, e.g. UserpassAuth, LDAPAuth,
+	// JWTAuth, TLSCertAuth, AWSAuth, GCPAuth or AzureAuth.
+	Login(ctx context.Context, method AuthMethod) error
+
+	// ReadKV reads a secret from mount at path, transparently handling KV
+	// v1 and v2 addressing.
+	ReadKV(ctx context.Context, mount, path string, opts ...KVOption) (*KVSecret, error)
+	// WriteKV writes data to mount at path, transparently handling KV v1
+	// and v2 addressing.
+	WriteKV(ctx context.Context, mount, path string, data map[string]interface{}) error
+	// DeleteKV deletes the latest (or, via KVVersion, specific) version of
+	// a secret.
+	DeleteKV(ctx context.Context, mount, path string, opts ...KVOption) error
+	// UndeleteKVVersions restores soft-deleted versions of a KV v2 secret.
+	UndeleteKVVersions(ctx context.Context, mount, path string, versions ...int) error
+	// DestroyKVVersions permanently removes the data for given versions of
+	// a KV v2 secret.
+	DestroyKVVersions(ctx context.Context, mount, path string, versions ...int) error
+	// ListKV lists the secret keys beneath path.
+	ListKV(ctx context.Context, mount, path string) ([]string, error)
+	// KVCredential reads path and decodes it into a UsernamePassword.
+	KVCredential(ctx context.Context, mount, path string) (*UsernamePassword, error)
+	// KVAPIKey reads path and decodes it into an APIKey.
+	KVAPIKey(ctx context.Context, mount, path string) (*APIKey, error)
+
+	// DBCredential checks out a dynamic database credential for role. Close
+	// the returned LeaseHandle once the credential is no longer needed.
+	DBCredential(ctx context.Context, role string) (*UsernamePassword, *LeaseHandle, error)
+	// AWSSTSCredential checks out dynamic, STS-issued AWS credentials for
+	// role. Close the returned LeaseHandle once the credential is no longer
+	// needed.
+	AWSSTSCredential(ctx context.Context, role string) (*AWSCreds, *LeaseHandle, error)
+	// Shutdown revokes every outstanding lease checked out through
+	// DBCredential, AWSSTSCredential and friends, and stops their renewers.
+	Shutdown() error
+
+	// Encrypt encrypts plaintext under key via the transit engine, returning
+	// Vault's versioned ciphertext string.
+	Encrypt(ctx context.Context, key string, plaintext []byte, opts ...TransitOption) (string, error)
+	// Decrypt decrypts a ciphertext string produced by Encrypt.
+	Decrypt(ctx context.Context, key, ciphertext string, opts ...TransitOption) ([]byte, error)
+	// Sign signs input with key, returning Vault's versioned signature
+	// string.
+	Sign(ctx context.Context, key string, input []byte, opts ...TransitOption) (string, error)
+	// Verify checks a signature produced by Sign against input.
+	Verify(ctx context.Context, key string, input []byte, signature string, opts ...TransitOption) (bool, error)
+	// HMAC computes an HMAC over input using key.
+	HMAC(ctx context.Context, key string, input []byte, opts ...TransitOption) (string, error)
+	// Rewrap re-encrypts ciphertext under the latest version of key without
+	// exposing the plaintext.
+	Rewrap(ctx context.Context, key, ciphertext string, opts ...TransitOption) (string, error)
+	// GenerateDataKey mints a data-encryption key of the given bit length,
+	// returning both its plaintext and a copy wrapped under key.
+	GenerateDataKey(ctx context.Context, key string, bits int) (*DataKey, error)
+	// EncryptStream envelope-encrypts r into w using a single data-encryption
+	// key from GenerateDataKey and local AES-GCM.
+	EncryptStream(ctx context.Context, key string, r io.Reader, w io.Writer) error
+	// DecryptStream reverses EncryptStream.
+	DecryptStream(ctx context.Context, key string, r io.Reader, w io.Writer) error
 }
 
 type secretManager struct {
 	client *vault.Client
+	ctx    context.Context
+
+	kvEnginesMu sync.RWMutex
+	kvEngines   map[string]KVEngine
+
+	leasesMu sync.Mutex
+	leases   map[string]*LeaseHandle
+
+	lifetimeMu  sync.Mutex
+	lifetimeMgr *LifetimeManager
 }
 
 func (s *secretManager) VaultClient() *vault.Client {
 	return s.client
 }
 
-func NewSecretManager(url string) (SecretManager, error) {
-	config := vault.DefaultConfig()
+// NewSecretManager connects to the Vault server at url. opts configure TLS,
+// namespace, retries, the underlying HTTP client, and the context used for
+// logins and lifetime management that don't take a context of their own.
+func NewSecretManager(url string, opts ...SecretManagerOption) (SecretManager, error) {
+	o, err := buildSecretManagerOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
 
+	config := vault.DefaultConfig()
 	config.Address = url
 
+	if o.tlsConfig != nil {
+		if err := config.ConfigureTLS(o.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	if o.httpClient != nil {
+		config.HttpClient = o.httpClient
+	}
+	if o.maxRetries >= 0 {
+		config.MaxRetries = o.maxRetries
+	}
+
 	client, err := vault.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
+	if o.namespace != "" {
+		client.SetNamespace(o.namespace)
+	}
 
-	return &secretManager{client: client}, nil
+	return &secretManager{
+		client:    client,
+		ctx:       o.ctx,
+		kvEngines: make(map[string]KVEngine),
+		leases:    make(map[string]*LeaseHandle),
+	}, nil
 }
 
 func (s *secretManager) LoginWithAppRole(approleId string, approleSecret string) error {
-	as := approleAuth.SecretID{FromString: approleSecret}
+	login := func(ctx context.Context) (*vault.Secret, error) {
+		as := approleAuth.SecretID{FromString: approleSecret}
 
-	approleAuth, err := approleAuth.NewAppRoleAuth(approleId, &as)
-	if err != nil {
-		return err
+		auth, err := approleAuth.NewAppRoleAuth(approleId, &as)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.client.Auth().Login(ctx, auth)
 	}
 
-	authInfo, err := s.client.Auth().Login(context.TODO(), approleAuth)
+	authInfo, err := login(s.ctx)
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		err = ManageSecretLifetime(s.client, authInfo, true)
-		if err != nil {
-			log.Fatal(err.Error())
-			return
-		}
-	}()
+	s.startLifetimeManager(authInfo, login)
 
 	return nil
 }
@@ -70,59 +164,79 @@ func (s *secretManager) LoginWithToken(token string) {
 }
 
 func (s *secretManager) LoginWithKubernetes(role string, opts ...kubernetesAuth.LoginOption) error {
-	k8sAuth, err := kubernetesAuth.NewKubernetesAuth(role, opts...)
-	if err != nil {
-		return err
+	login := func(ctx context.Context) (*vault.Secret, error) {
+		k8sAuth, err := kubernetesAuth.NewKubernetesAuth(role, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.client.Auth().Login(ctx, k8sAuth)
 	}
 
-	authSecret, err := s.client.Auth().Login(context.TODO(), k8sAuth)
+	authSecret, err := login(s.ctx)
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		err = ManageSecretLifetime(s.client, authSecret, true)
-		if err != nil {
-			log.Fatal(err.Error())
-			return
-		}
-	}()
+	s.startLifetimeManager(authSecret, login)
 
 	return nil
 }
 
-func ManageSecretLifetime(client *vault.Client, secret *vault.Secret, isAuthToken bool) error {
-	if isAuthToken {
-		if !secret.Auth.Renewable {
-			return errors.New("secret is not renewable")
-		}
-	} else {
-		if !secret.Renewable {
-			return errors.New("secret is not renewable")
-		}
+// Login authenticates via method and starts proactive renewal, the same as
+// LoginWithAppRole and LoginWithKubernetes, but for any AuthMethod.
+func (s *secretManager) Login(ctx context.Context, method AuthMethod) error {
+	login := func(ctx context.Context) (*vault.Secret, error) {
+		// Go through client.Auth().Login rather than method.Login directly:
+		// it's the step that sets the client's token from the response, and
+		// every AuthMethod (ours and the stock AppRole/Kubernetes ones) only
+		// writes to the login endpoint and returns the secret.
+		return s.client.Auth().Login(ctx, method)
 	}
 
-	watcher, err := client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{
-		Secret:    secret,
-		Increment: 3600,
-	})
+	secret, err := login(ctx)
 	if err != nil {
 		return err
 	}
 
-	go watcher.Start()
-	defer watcher.Stop()
+	s.startLifetimeManager(secret, login)
 
-	for {
-		select {
-		case err := <-watcher.DoneCh():
-			if err != nil {
-				return err
-			}
-			return nil
+	return nil
+}
 
-		case renewal := <-watcher.RenewCh():
-			logger.Info("Token was renewed", "renewedAt", renewal.RenewedAt)
-		}
+// startLifetimeManager replaces any previously running LifetimeManager with
+// one that renews secret, re-authenticating via login when renewal is no
+// longer possible. The manager runs until s.ctx is cancelled, it is stopped
+// through lifetimeMgr, or re-authentication fails.
+func (s *secretManager) startLifetimeManager(secret *vault.Secret, login loginFunc) {
+	mgr := newLifetimeManager(s.client, secret, login)
+
+	s.lifetimeMu.Lock()
+	previous := s.lifetimeMgr
+	s.lifetimeMgr = mgr
+	s.lifetimeMu.Unlock()
+
+	if previous != nil {
+		previous.Stop()
 	}
+
+	go func() {
+		for err := range mgr.Errors() {
+			logger.Error("lifetime manager error", "error", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := mgr.Start(s.ctx); err != nil {
+			logger.Error("lifetime manager stopped", "error", err.Error())
+		}
+	}()
+}
+
+// currentLifetimeManager returns the LifetimeManager started by the most
+// recent login, if any.
+func (s *secretManager) currentLifetimeManager() *LifetimeManager {
+	s.lifetimeMu.Lock()
+	defer s.lifetimeMu.Unlock()
+	return s.lifetimeMgr
 }
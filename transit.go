@@ -0,0 +1,339 @@
+package secretManager
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// transitStreamChunkSize is the amount of plaintext sealed into a single
+// AES-GCM chunk by EncryptStream/DecryptStream.
+const transitStreamChunkSize = 64 * 1024
+
+// maxWrappedKeyFrameLen bounds the wrapped data-key frame DecryptStream reads
+// before it has any way to authenticate the length prefix. Vault's
+// versioned ciphertext strings are well under this.
+const maxWrappedKeyFrameLen = 8 * 1024
+
+// TransitOption configures a single transit operation.
+type TransitOption func(*transitOptions)
+
+type transitOptions struct {
+	keyVersion int
+	context    []byte
+}
+
+// WithKeyVersion pins an operation to a specific transit key version.
+func WithKeyVersion(version int) TransitOption {
+	return func(o *transitOptions) { o.keyVersion = version }
+}
+
+// WithTransitContext supplies the base64-free context used by convergent or
+// derived transit keys.
+func WithTransitContext(transitContext []byte) TransitOption {
+	return func(o *transitOptions) { o.context = transitContext }
+}
+
+func buildTransitOptions(opts ...TransitOption) transitOptions {
+	var o transitOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o transitOptions) apply(data map[string]interface{}) {
+	if o.keyVersion > 0 {
+		data["key_version"] = o.keyVersion
+	}
+	if len(o.context) > 0 {
+		data["context"] = base64.StdEncoding.EncodeToString(o.context)
+	}
+}
+
+// DataKey is the result of GenerateDataKey: a data-encryption key in the
+// clear for local use, and the same key wrapped under a transit key so it
+// can be stored alongside whatever it encrypted.
+type DataKey struct {
+	Plaintext  []byte
+	Ciphertext string
+}
+
+func (s *secretManager) transitWrite(ctx context.Context, op, key string, data map[string]interface{}) (*vault.Secret, error) {
+	secret, err := s.client.Logical().WriteWithContext(ctx, fmt.Sprintf("transit/%s/%s", op, key), data)
+	if err != nil {
+		return nil, fmt.Errorf("transit %s with key %s: %w", op, key, err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("transit %s with key %s: empty response", op, key)
+	}
+	return secret, nil
+}
+
+// Encrypt encrypts plaintext under key, returning Vault's versioned
+// ciphertext string (e.g. "vault:v1:...").
+func (s *secretManager) Encrypt(ctx context.Context, key string, plaintext []byte, opts ...TransitOption) (string, error) {
+	data := map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "encrypt", key, data)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, _ := secret.Data["ciphertext"].(string)
+	return ciphertext, nil
+}
+
+// Decrypt decrypts a Vault transit ciphertext string produced by Encrypt.
+func (s *secretManager) Decrypt(ctx context.Context, key, ciphertext string, opts ...TransitOption) ([]byte, error) {
+	data := map[string]interface{}{"ciphertext": ciphertext}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "decrypt", key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _ := secret.Data["plaintext"].(string)
+	plaintext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding plaintext: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Sign signs input with key, returning Vault's versioned signature string.
+func (s *secretManager) Sign(ctx context.Context, key string, input []byte, opts ...TransitOption) (string, error) {
+	data := map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString(input),
+	}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "sign", key, data)
+	if err != nil {
+		return "", err
+	}
+
+	signature, _ := secret.Data["signature"].(string)
+	return signature, nil
+}
+
+// Verify checks signature, produced by Sign, against input.
+func (s *secretManager) Verify(ctx context.Context, key string, input []byte, signature string, opts ...TransitOption) (bool, error) {
+	data := map[string]interface{}{
+		"input":     base64.StdEncoding.EncodeToString(input),
+		"signature": signature,
+	}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "verify", key, data)
+	if err != nil {
+		return false, err
+	}
+
+	valid, _ := secret.Data["valid"].(bool)
+	return valid, nil
+}
+
+// HMAC computes an HMAC over input using key.
+func (s *secretManager) HMAC(ctx context.Context, key string, input []byte, opts ...TransitOption) (string, error) {
+	data := map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString(input),
+	}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "hmac", key, data)
+	if err != nil {
+		return "", err
+	}
+
+	hmac, _ := secret.Data["hmac"].(string)
+	return hmac, nil
+}
+
+// Rewrap re-encrypts ciphertext under the latest (or, via WithKeyVersion, a
+// specific) version of key, without exposing the plaintext.
+func (s *secretManager) Rewrap(ctx context.Context, key, ciphertext string, opts ...TransitOption) (string, error) {
+	data := map[string]interface{}{"ciphertext": ciphertext}
+	buildTransitOptions(opts...).apply(data)
+
+	secret, err := s.transitWrite(ctx, "rewrap", key, data)
+	if err != nil {
+		return "", err
+	}
+
+	rewrapped, _ := secret.Data["ciphertext"].(string)
+	return rewrapped, nil
+}
+
+// GenerateDataKey asks key to mint a new data-encryption key of the given
+// bit length, returning both its plaintext and a copy wrapped under key.
+// Used internally by EncryptStream for envelope encryption; DecryptStream
+// unwraps the key with Decrypt instead.
+func (s *secretManager) GenerateDataKey(ctx context.Context, key string, bits int) (*DataKey, error) {
+	secret, err := s.transitWrite(ctx, "datakey/plaintext", key, map[string]interface{}{"bits": bits})
+	if err != nil {
+		return nil, err
+	}
+
+	encodedPlaintext, _ := secret.Data["plaintext"].(string)
+	plaintext, err := base64.StdEncoding.DecodeString(encodedPlaintext)
+	if err != nil {
+		return nil, fmt.Errorf("decoding data key: %w", err)
+	}
+
+	ciphertext, _ := secret.Data["ciphertext"].(string)
+	return &DataKey{Plaintext: plaintext, Ciphertext: ciphertext}, nil
+}
+
+// EncryptStream envelope-encrypts r into w: it mints a single data-encryption
+// key via GenerateDataKey, writes the key wrapped under key, then encrypts
+// the stream locally in AES-GCM chunks so large blobs never have to pass
+// through Vault themselves.
+func (s *secretManager) EncryptStream(ctx context.Context, key string, r io.Reader, w io.Writer) error {
+	dataKey, err := s.GenerateDataKey(ctx, key, 256)
+	if err != nil {
+		return err
+	}
+	defer zero(dataKey.Plaintext)
+
+	gcm, err := newGCM(dataKey.Plaintext)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFramed(w, []byte(dataKey.Ciphertext)); err != nil {
+		return fmt.Errorf("writing wrapped data key: %w", err)
+	}
+
+	buf := make([]byte, transitStreamChunkSize)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			nonce := make([]byte, gcm.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return fmt.Errorf("generating nonce: %w", err)
+			}
+
+			if err := writeFramed(w, gcm.Seal(nonce, nonce, buf[:n], nil)); err != nil {
+				return fmt.Errorf("writing ciphertext chunk: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("reading plaintext: %w", readErr)
+		}
+	}
+}
+
+// DecryptStream reverses EncryptStream: it unwraps the data-encryption key
+// via Decrypt, then decrypts each AES-GCM chunk locally.
+func (s *secretManager) DecryptStream(ctx context.Context, key string, r io.Reader, w io.Writer) error {
+	wrappedDataKey, err := readFramed(r, maxWrappedKeyFrameLen)
+	if err != nil {
+		return fmt.Errorf("reading wrapped data key: %w", err)
+	}
+
+	plaintextKey, err := s.Decrypt(ctx, key, string(wrappedDataKey))
+	if err != nil {
+		return fmt.Errorf("unwrapping data key: %w", err)
+	}
+	defer zero(plaintextKey)
+
+	gcm, err := newGCM(plaintextKey)
+	if err != nil {
+		return err
+	}
+
+	// A sealed chunk is at most a plaintext chunk plus the nonce and GCM
+	// authentication tag; bound the length prefix to that before allocating,
+	// since it's read off the wire before any of it has been authenticated.
+	maxChunkFrameLen := transitStreamChunkSize + gcm.NonceSize() + gcm.Overhead()
+
+	for {
+		chunk, err := readFramed(r, maxChunkFrameLen)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading ciphertext chunk: %w", err)
+		}
+
+		nonceSize := gcm.NonceSize()
+		if len(chunk) < nonceSize {
+			return fmt.Errorf("ciphertext chunk shorter than nonce")
+		}
+
+		plaintext, err := gcm.Open(nil, chunk[:nonceSize], chunk[nonceSize:], nil)
+		if err != nil {
+			return fmt.Errorf("decrypting chunk: %w", err)
+		}
+		if _, err := w.Write(plaintext); err != nil {
+			return fmt.Errorf("writing plaintext: %w", err)
+		}
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("building AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("building AES-GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+func writeFramed(w io.Writer, data []byte) error {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFramed reads a length-prefixed frame, rejecting a length beyond maxLen
+// before allocating: the prefix comes straight off the wire, ahead of any
+// GCM authentication, so an unbounded allocation here is a trivial OOM for a
+// corrupt or hostile envelope.
+func readFramed(r io.Reader, maxLen int) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	frameLen := binary.BigEndian.Uint32(length[:])
+	if frameLen > uint32(maxLen) {
+		return nil, fmt.Errorf("framed length %d exceeds maximum of %d", frameLen, maxLen)
+	}
+
+	data := make([]byte, frameLen)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}